@@ -11,7 +11,7 @@ func root(writer http.ResponseWriter, request *http.Request) {
 }
 
 func entry(writer http.ResponseWriter, request *http.Request) {
-	fmt.Fprint(writer, "Entry: " + request.URL.Query().Get("id") + "\n")
+	fmt.Fprint(writer, "Entry: " + router.Param(request, "id") + "\n")
 }
 
 func main() {
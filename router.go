@@ -1,39 +1,262 @@
 package router
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
 	"strings"
 )
 
 type Router struct {
-	Routes map[string][]*Route
 	NotFoundHandler http.Handler
+
+	// MethodNotAllowedHandler is served, with an Allow header listing the
+	// registered methods, when a path matches but the request method does
+	// not. Only takes effect when HandleMethodNotAllowed is true.
+	MethodNotAllowedHandler http.Handler
+
+	// HandleMethodNotAllowed makes the router respond via
+	// MethodNotAllowedHandler (default 405) instead of NotFoundHandler when
+	// the path matches at least one registered route but the method
+	// doesn't. Disabled by default.
+	HandleMethodNotAllowed bool
+
+	// AutoOptions makes the router auto-serve HEAD for any GET route (by
+	// running the GET handler with a body-discarding writer) and
+	// auto-respond to OPTIONS with an Allow header listing the registered
+	// methods. Disabled by default.
+	AutoOptions bool
+
+	// MiddlewareOnNotFound makes the router run NotFoundHandler through
+	// the registered middleware chain instead of calling it directly,
+	// matching the "middleware runs even on unmatched" semantic found in
+	// gorilla mux. Disabled by default.
+	MiddlewareOnNotFound bool
+
+	// LegacyQueryParams makes matched routes merge path params into
+	// request.URL.RawQuery, as the router always did before Param/Params
+	// existed. Disabled by default; turn this on only while migrating
+	// handlers that still read path params via request.URL.Query().
+	LegacyQueryParams bool
+
 	host string
+	middlewares []func(http.Handler) http.Handler
+	root *trieNode
+	handlers *routeList
+	namedRoutes map[string]*Route
+	mounts *mountList
 }
 
 var notFoundHandler http.Handler = http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 	http.Error(writer, "Not Found", 404)
 })
 
+var methodNotAllowedHandler http.Handler = http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+	http.Error(writer, "Method Not Allowed", 405)
+})
+
 func NewRouter() *Router {
 	return &Router{
-		Routes: make(map[string][]*Route),
 		NotFoundHandler: notFoundHandler,
+		MethodNotAllowedHandler: methodNotAllowedHandler,
+		root: newTrieNode(),
+		handlers: &routeList{},
+		namedRoutes: make(map[string]*Route),
+		mounts: &mountList{},
 	}
 }
 
 func (router *Router) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	if router.Match(request) {
-		for _, route := range append(router.Routes[request.Method], router.Routes["ANY"]...) {
-			if route.Match(request.URL.Path) {
-				route.ServeHTTP(writer, request)
-				return
-			}
+	if !router.Match(request) {
+		router.notFoundHandler().ServeHTTP(writer, request)
+		return
+	}
+
+	path := request.URL.Path
+	if route := router.lookup(request.Method, path); route != nil {
+		route.ServeHTTP(writer, request)
+		return
+	}
+
+	if handler, rest, ok := router.mounts.match(path); ok {
+		original := request.URL.Path
+		request.URL.Path = rest
+		defer func() { request.URL.Path = original }()
+		chain(router.middlewares, handler).ServeHTTP(writer, request)
+		return
+	}
+
+	if router.AutoOptions && request.Method == "HEAD" {
+		if route := router.lookup("GET", path); route != nil {
+			route.ServeHTTP(&headResponseWriter{writer}, request)
+			return
+		}
+	}
+
+	if router.AutoOptions && request.Method == "OPTIONS" {
+		if methods := router.allowedMethods(path); len(methods) > 0 {
+			writer.Header().Set("Allow", strings.Join(methods, ", "))
+			writer.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	if router.HandleMethodNotAllowed {
+		if methods := router.allowedMethods(path); len(methods) > 0 {
+			writer.Header().Set("Allow", strings.Join(methods, ", "))
+			router.MethodNotAllowedHandler.ServeHTTP(writer, request)
+			return
+		}
+	}
+
+	for _, route := range router.handlers.routes {
+		route.ServeHTTP(writer, request)
+		return
+	}
+	router.notFoundHandler().ServeHTTP(writer, request)
+}
+
+// allowedMethods returns the sorted, deduplicated set of methods registered
+// for path across every matching route pattern (static, param or
+// wildcard), used to build the Allow header for 405 and OPTIONS responses.
+func (router *Router) allowedMethods(path string) []string {
+	methods := make(map[string]bool)
+	router.root.collectMethods(strings.Split(path, "/"), path, methods)
+	list := make([]string, 0, len(methods))
+	for method := range methods {
+		list = append(list, method)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// headResponseWriter discards the response body so a GET handler can be
+// reused to auto-serve HEAD without writing anything but headers.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (writer *headResponseWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+// lookup descends the trie once per request, preferring a static segment
+// match over a param match over a wildcard match at every level.
+func (router *Router) lookup(method, path string) *Route {
+	route, ok := router.root.lookup(strings.Split(path, "/"), method, path)
+	if !ok {
+		return nil
+	}
+	return route
+}
+
+// notFoundHandler returns NotFoundHandler, wrapped in the router's
+// middleware chain when MiddlewareOnNotFound is enabled.
+func (router *Router) notFoundHandler() http.Handler {
+	if router.MiddlewareOnNotFound {
+		return chain(router.middlewares, router.NotFoundHandler)
+	}
+	return router.NotFoundHandler
+}
+
+// Use appends middleware that wraps every route dispatched by this router.
+// Middleware only applies to routes registered after the call, so Use
+// should be called before routes are defined.
+func (router *Router) Use(mw ...func(http.Handler) http.Handler) {
+	router.middlewares = append(router.middlewares, mw...)
+}
+
+// Group registers a subset of routes that share an isolated middleware
+// stack: mw added inside fn via Use does not leak back into router. Routes
+// registered inside fn are merged into the same trie as router's, so they
+// dispatch exactly like a route registered directly on router.
+func (router *Router) Group(fn func(*Router)) {
+	group := &Router{
+		NotFoundHandler: router.NotFoundHandler,
+		MethodNotAllowedHandler: router.MethodNotAllowedHandler,
+		HandleMethodNotAllowed: router.HandleMethodNotAllowed,
+		AutoOptions: router.AutoOptions,
+		MiddlewareOnNotFound: router.MiddlewareOnNotFound,
+		LegacyQueryParams: router.LegacyQueryParams,
+		host: router.host,
+		middlewares: append([]func(http.Handler) http.Handler{}, router.middlewares...),
+		root: router.root,
+		handlers: router.handlers,
+		namedRoutes: router.namedRoutes,
+		mounts: router.mounts,
+	}
+	fn(group)
+}
+
+// Mount delegates to sub every request whose path is prefix or lies under
+// prefix + "/" and that matches none of router's own routes (those are
+// looked up first), stripping prefix from request.URL.Path first and
+// restoring the original path once sub returns (so outer logging middleware still sees it).
+// Host constraints on router still apply, since ServeHTTP checks those
+// before ever reaching the mount table.
+func (router *Router) Mount(prefix string, sub http.Handler) {
+	router.mounts.entries = append(router.mounts.entries, &mountEntry{
+		prefix: strings.TrimSuffix(prefix, "/"),
+		handler: sub,
+	})
+}
+
+// PathPrefix creates and mounts a subrouter at prefix, so /api/v2-style
+// trees can be assembled declaratively instead of through NotFoundHandler
+// fallthrough, e.g. apiRouter := router.PathPrefix("/api/v2").
+func (router *Router) PathPrefix(prefix string) *Router {
+	sub := NewRouter()
+	router.Mount(prefix, sub)
+	return sub
+}
+
+type mountEntry struct {
+	prefix string
+	handler http.Handler
+}
+
+// mountList is a mutable slice of *mountEntry shared by pointer between a
+// router and its Group subrouters, mirroring routeList.
+type mountList struct {
+	entries []*mountEntry
+}
+
+// match returns the first mount whose prefix matches path, along with path
+// stripped of that prefix.
+func (list *mountList) match(path string) (http.Handler, string, bool) {
+	for _, entry := range list.entries {
+		if rest, ok := stripPrefix(path, entry.prefix); ok {
+			return entry.handler, rest, true
 		}
 	}
-	router.NotFoundHandler.ServeHTTP(writer, request)
+	return nil, "", false
+}
+
+// stripPrefix reports whether path falls under prefix, returning the
+// remainder with a leading "/". An empty prefix matches every path as-is.
+func stripPrefix(path, prefix string) (string, bool) {
+	switch {
+	case prefix == "":
+		return path, true
+	case path == prefix:
+		return "/", true
+	case strings.HasPrefix(path, prefix+"/"):
+		return path[len(prefix):], true
+	default:
+		return "", false
+	}
+}
+
+// chain wraps final with middlewares so that middlewares[0] runs outermost.
+func chain(middlewares []func(http.Handler) http.Handler, final http.Handler) http.Handler {
+	handler := final
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
 }
 
 func (router *Router) Host(host string) {
@@ -48,32 +271,206 @@ func (router *Router) MatchHost(host string) bool {
 	return router.host == "" || router.host == strings.Split(host, ":")[0]
 }
 
-func (router *Router) Get(pattern string, handlerOrFunc interface{}) {
-	router.AppendRoute("GET", pattern, handlerOrFunc)
+func (router *Router) Get(pattern string, handlerOrFunc interface{}) *Route {
+	return router.AppendRoute("GET", pattern, handlerOrFunc)
 }
 
-func (router *Router) Post(pattern string, handlerOrFunc interface{}) {
-	router.AppendRoute("POST", pattern, handlerOrFunc)
+func (router *Router) Post(pattern string, handlerOrFunc interface{}) *Route {
+	return router.AppendRoute("POST", pattern, handlerOrFunc)
 }
 
-func (router *Router) Put(pattern string, handlerOrFunc interface{}) {
-	router.AppendRoute("PUT", pattern, handlerOrFunc)
+func (router *Router) Put(pattern string, handlerOrFunc interface{}) *Route {
+	return router.AppendRoute("PUT", pattern, handlerOrFunc)
 }
 
-func (router *Router) Delete(pattern string, handlerOrFunc interface{}) {
-	router.AppendRoute("DELETE", pattern, handlerOrFunc)
+func (router *Router) Delete(pattern string, handlerOrFunc interface{}) *Route {
+	return router.AppendRoute("DELETE", pattern, handlerOrFunc)
 }
 
-func (router *Router) Any(pattern string, handlerOrFunc interface{}) {
-	router.AppendRoute("ANY", pattern, handlerOrFunc)
+func (router *Router) Any(pattern string, handlerOrFunc interface{}) *Route {
+	return router.AppendRoute("ANY", pattern, handlerOrFunc)
 }
 
 func (router *Router) Handle(handlerOrFunc interface{}) {
-	router.Routes["ANY"] = append(router.Routes["ANY"], NewEmptyRoute(handlerOrFunc))
+	router.handlers.routes = append(router.handlers.routes, NewEmptyRoute(chain(router.middlewares, convertToHandler(handlerOrFunc))))
 }
 
-func (router *Router) AppendRoute(method, pattern string, handlerOrFunc interface{}) {
-	router.Routes[method] = append(router.Routes[method], NewRoute(pattern, handlerOrFunc))
+func (router *Router) AppendRoute(method, pattern string, handlerOrFunc interface{}) *Route {
+	route := NewRoute(pattern, handlerOrFunc)
+	route.router = router
+	route.routerMiddlewares = router.middlewares
+	route.Handler = chain(router.middlewares, route.base)
+	router.root.insert(splitPattern(pattern), method, route)
+	return route
+}
+
+// URL reverses the route registered under name by substituting each of its
+// placeholder segments (:key, {key}, {key:regex} or *key) with the given
+// key-value pairs, path-escaping each value. This lets handlers build links
+// without hardcoding paths, e.g. router.URL("entry", "id", "42").
+func (router *Router) URL(name string, pairs ...string) (*url.URL, error) {
+	route, ok := router.namedRoutes[name]
+	if !ok {
+		return nil, fmt.Errorf("router: no route named %q", name)
+	}
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("router: URL pairs must be given as key, value, ...")
+	}
+	values := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		values[pairs[i]] = pairs[i+1]
+	}
+	segments := splitPattern(route.pattern)
+	for i, segment := range segments {
+		var key string
+		switch {
+		case strings.HasPrefix(segment, "*"):
+			key = segment[1:]
+		case isParamSegment(segment):
+			key = paramSegmentName(segment)
+		default:
+			continue
+		}
+		value, ok := values[key]
+		if !ok {
+			return nil, fmt.Errorf("router: missing value for %q", key)
+		}
+		segments[i] = url.PathEscape(value)
+	}
+	return url.Parse(strings.Join(segments, "/"))
+}
+
+// routeList is a mutable slice of *Route shared by pointer between a router
+// and its Group subrouters, so a Handle call from inside a group stays
+// visible to the parent router.
+type routeList struct {
+	routes []*Route
+}
+
+// trieNode is one segment of the registered route patterns. Children are
+// keyed by literal segment; paramChild and wildcardChild hold the single
+// :name and *name segments allowed at that level.
+type trieNode struct {
+	children map[string]*trieNode
+	paramChild *trieNode
+	paramName string
+	wildcardChild *trieNode
+	wildcardName string
+	routes map[string]*Route
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{
+		children: make(map[string]*trieNode),
+		routes: make(map[string]*Route),
+	}
+}
+
+func (node *trieNode) insert(segments []string, method string, route *Route) {
+	if len(segments) == 0 {
+		node.routes[method] = route
+		return
+	}
+
+	segment, rest := segments[0], segments[1:]
+	switch {
+	case strings.HasPrefix(segment, "*"):
+		if node.wildcardChild == nil {
+			node.wildcardChild = newTrieNode()
+			node.wildcardName = segment[1:]
+		}
+		node.wildcardChild.insert(rest, method, route)
+	case isParamSegment(segment):
+		if node.paramChild == nil {
+			node.paramChild = newTrieNode()
+			node.paramName = paramSegmentName(segment)
+		}
+		node.paramChild.insert(rest, method, route)
+	default:
+		child, ok := node.children[segment]
+		if !ok {
+			child = newTrieNode()
+			node.children[segment] = child
+		}
+		child.insert(rest, method, route)
+	}
+}
+
+// lookup descends the trie, preferring a static match over a param match
+// over a wildcard match at every level, and falls back from method to ANY
+// once a leaf is reached. path is the full request path, used to confirm a
+// candidate leaf's full pattern (e.g. a {id:[0-9]+} constraint) actually
+// matches before accepting it, backtracking to sibling branches otherwise.
+func (node *trieNode) lookup(segments []string, method string, path string) (*Route, bool) {
+	if len(segments) == 0 {
+		return node.route(method, path)
+	}
+
+	segment, rest := segments[0], segments[1:]
+	if child, ok := node.children[segment]; ok {
+		if route, found := child.lookup(rest, method, path); found {
+			return route, true
+		}
+	}
+	if node.paramChild != nil {
+		if route, found := node.paramChild.lookup(rest, method, path); found {
+			return route, true
+		}
+	}
+	if node.wildcardChild != nil {
+		if route, found := node.wildcardChild.route(method, path); found {
+			return route, true
+		}
+	}
+	return nil, false
+}
+
+// collectMethods gathers, into methods, every non-ANY HTTP method whose
+// route pattern matches path, descending into every viable branch instead
+// of stopping at the first one like lookup does.
+func (node *trieNode) collectMethods(segments []string, path string, methods map[string]bool) {
+	if len(segments) == 0 {
+		for method, route := range node.routes {
+			if method != "ANY" && route.Pattern.MatchString(path) {
+				methods[method] = true
+			}
+		}
+		return
+	}
+
+	segment, rest := segments[0], segments[1:]
+	if child, ok := node.children[segment]; ok {
+		child.collectMethods(rest, path, methods)
+	}
+	if node.paramChild != nil {
+		node.paramChild.collectMethods(rest, path, methods)
+	}
+	if node.wildcardChild != nil {
+		node.wildcardChild.collectMethods(nil, path, methods)
+	}
+}
+
+func (node *trieNode) route(method string, path string) (*Route, bool) {
+	if route, ok := node.routes[method]; ok && route.Pattern.MatchString(path) {
+		return route, true
+	}
+	if route, ok := node.routes["ANY"]; ok && route.Pattern.MatchString(path) {
+		return route, true
+	}
+	return nil, false
+}
+
+// isParamSegment reports whether segment is a :name or {name[:regex]}
+// placeholder, as opposed to a literal segment.
+func isParamSegment(segment string) bool {
+	return strings.HasPrefix(segment, ":") || constrainedPlaceholderMatcher.MatchString(segment)
+}
+
+func paramSegmentName(segment string) string {
+	if matches := constrainedPlaceholderMatcher.FindStringSubmatch(segment); matches != nil {
+		return matches[1]
+	}
+	return strings.TrimPrefix(segment, ":")
 }
 
 var (
@@ -82,58 +479,186 @@ var (
 
 	// Precompile Regexp to speed things up.
 	placeholderMatcher *regexp.Regexp = regexp.MustCompile(`:(\w+)`)
+
+	// Matches a whole {name} or {name:regex} segment.
+	constrainedPlaceholderMatcher *regexp.Regexp = regexp.MustCompile(`^\{(\w+)(?::(.+))?\}$`)
 )
 
 type Route struct {
 	Pattern *regexp.Regexp
 	Keys []string
 	Handler http.Handler
+
+	pattern string
+	name string
+	router *Router
+	base http.Handler
+	routerMiddlewares []func(http.Handler) http.Handler
+	withMiddlewares []func(http.Handler) http.Handler
 }
 
 func NewRoute(pattern string, handlerOrFunc interface{}) *Route {
 	regexp, keys := compilePattern(pattern)
-	return &Route{regexp, keys, convertToHandler(handlerOrFunc)}
+	handler := convertToHandler(handlerOrFunc)
+	return &Route{
+		Pattern: regexp,
+		Keys: keys,
+		Handler: handler,
+		pattern: pattern,
+		base: handler,
+	}
 }
 
 func NewEmptyRoute(handlerOrFunc interface{}) *Route {
-	return &Route{anythingMatcher, make([]string, 0), convertToHandler(handlerOrFunc)}
+	return &Route{
+		Pattern: anythingMatcher,
+		Keys: make([]string, 0),
+		Handler: convertToHandler(handlerOrFunc),
+	}
 }
 
 func (route *Route) Match(path string) bool {
 	return route.Pattern.MatchString(path)
 }
 
+// Name registers route under name on its router, so Router.URL(name, ...)
+// can later reverse it into a concrete path. Returns route for chaining.
+func (route *Route) Name(name string) *Route {
+	route.name = name
+	if route.router != nil {
+		route.router.namedRoutes[name] = route
+	}
+	return route
+}
+
+// With wraps the route's handler in mw, applied in order so that mw[0]
+// runs outermost, nesting inside any middleware registered on the router
+// via Use so the router's global middleware (e.g. recovery) stays
+// outermost. Returns route itself for chaining, e.g.
+// router.Get("/admin", handler).With(authMiddleware, loggingMiddleware).
+func (route *Route) With(mw ...func(http.Handler) http.Handler) *Route {
+	route.withMiddlewares = append(route.withMiddlewares, mw...)
+	route.Handler = chain(route.routerMiddlewares, chain(route.withMiddlewares, route.base))
+	return route
+}
+
 func (route *Route) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	params := request.URL.Query()
-	for key, values := range route.extractParams(request.URL.Path) {
-		params[key] = append(params[key], values...)
+	params := route.extractParams(request.URL.Path)
+	if len(params) > 0 {
+		request = request.WithContext(context.WithValue(request.Context(), paramsContextKey, params))
+	}
+	if route.router != nil && route.router.LegacyQueryParams {
+		values := request.URL.Query()
+		for key, value := range params {
+			values.Add(key, value)
+		}
+		request.URL.RawQuery = values.Encode()
 	}
-	request.URL.RawQuery = params.Encode()
 	route.Handler.ServeHTTP(writer, request)
 }
 
-func (route *Route) extractParams(path string) url.Values {
-	params := make(url.Values)
-	for i, param := range route.Pattern.FindStringSubmatch(path)[1:] {
-		params[route.Keys[i]] = append(params[route.Keys[i]], param)
+func (route *Route) extractParams(path string) map[string]string {
+	matches := route.Pattern.FindStringSubmatch(path)
+	if len(matches) == 0 {
+		return nil
 	}
+	params := make(map[string]string, len(route.Keys))
+	for i, key := range route.Keys {
+		params[key] = matches[i+1]
+	}
+	return params
+}
+
+// paramsContextKeyType is an unexported type for paramsContextKey so it
+// can't collide with context keys set by other packages.
+type paramsContextKeyType int
+
+// paramsContextKey is the context.Context key under which a matched
+// route's path params are stored, read back by Param and Params.
+const paramsContextKey paramsContextKeyType = 0
+
+// Param returns the value of the path param key extracted for request by
+// the route that matched it, or "" if there is no such param. Path params
+// are available this way regardless of Router.LegacyQueryParams.
+func Param(request *http.Request, key string) string {
+	return Params(request)[key]
+}
+
+// Params returns every path param extracted for request by the route
+// that matched it, or nil if the route captured none.
+func Params(request *http.Request) map[string]string {
+	params, _ := request.Context().Value(paramsContextKey).(map[string]string)
 	return params
 }
 
+// compilePattern compiles a pattern into a whole-path regexp plus the
+// ordered list of param names it captures. Each "/"-separated segment may
+// be a literal, a bare ":name" or constrained "{name:regex}" placeholder
+// capturing a single segment, or a trailing "*name" catch-all capturing
+// the rest of the path.
+//
 // compilePattern("/hello/:world") => ^\/hello\/([^#?/]+)$, ["world"]
+// compilePattern("/users/{id:[0-9]+}") => ^\/users\/([0-9]+)$, ["id"]
+// compilePattern("/files/*path") => ^\/files\/(.+)$, ["path"]
 func compilePattern(pattern string) (*regexp.Regexp, []string) {
 	var segments, keys []string
-	for _, segment := range strings.Split(pattern, "/") {
-		if strings := placeholderMatcher.FindStringSubmatch(segment); strings != nil {
-			keys = append(keys, strings[1])
+	for _, segment := range splitPattern(pattern) {
+		switch {
+		case strings.HasPrefix(segment, "*"):
+			key := segment[1:]
+			if key == "" {
+				panic("router: wildcard segment requires a name, e.g. *path")
+			}
+			keys = append(keys, key)
+			segments = append(segments, "(.+)")
+		case constrainedPlaceholderMatcher.MatchString(segment):
+			matches := constrainedPlaceholderMatcher.FindStringSubmatch(segment)
+			key, constraint := matches[1], matches[2]
+			if constraint == "" {
+				constraint = "[^#?/]+"
+			} else if regexp.MustCompile(constraint).MatchString("/") {
+				panic(fmt.Sprintf("router: constraint for %q must not match /, use *%s for a catch-all segment", key, key))
+			}
+			keys = append(keys, key)
+			segments = append(segments, "("+constraint+")")
+		case placeholderMatcher.MatchString(segment):
+			matches := placeholderMatcher.FindStringSubmatch(segment)
+			keys = append(keys, matches[1])
 			segments = append(segments, placeholderMatcher.ReplaceAllString(segment, "([^#?/]+)"))
-		} else {
+		default:
 			segments = append(segments, segment)
 		}
 	}
 	return regexp.MustCompile(`^` + strings.Join(segments, `\/`) + "$"), keys
 }
 
+// splitPattern splits a route pattern on "/" like strings.Split, except it
+// treats a whole "{...}" placeholder as atomic, so a "/" embedded in a
+// constraint regex (e.g. "{id:[^/]+}") doesn't get cut into fragments that
+// no longer match constrainedPlaceholderMatcher.
+func splitPattern(pattern string) []string {
+	var segments []string
+	var current strings.Builder
+	depth := 0
+	for _, r := range pattern {
+		switch {
+		case r == '{':
+			depth++
+			current.WriteRune(r)
+		case r == '}':
+			depth--
+			current.WriteRune(r)
+		case r == '/' && depth == 0:
+			segments = append(segments, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	segments = append(segments, current.String())
+	return segments
+}
+
 // Converts interface{} to http.Handler so that router can take Handler or HandlerFunc.
 func convertToHandler(handlerOrFunc interface{}) (handler http.Handler) {
 	if _, ok := handlerOrFunc.(http.Handler); ok {
@@ -32,6 +32,12 @@ func TestRoute(t *testing.T) {
 		{"/a/:b", "/a/b/c", false},
 		{"/a/:b/c", "/a/b/c", true},
 		{"/a/:b/c", "/a/b/c/d", false},
+		{"/users/{id:[0-9]+}", "/users/42", true},
+		{"/users/{id:[0-9]+}", "/users/abc", false},
+		{"/users/{id}", "/users/abc", true},
+		{"/users/{id:[^/]+}", "/users/abc", true},
+		{"/files/*path", "/files/a/b/c.txt", true},
+		{"/files/*path", "/files/", false},
 	}
 	Describe(t, "router.Route#Match", func() {
 		for _, example := range examples {
@@ -67,12 +73,13 @@ func request(router http.Handler, method, path string) *httptest.ResponseRecorde
 }
 
 func dummyHandler(writer http.ResponseWriter, request *http.Request) {
-	fmt.Fprintf(writer, request.URL.Path + "?" + request.URL.RawQuery)
+	fmt.Fprint(writer, request.URL.Path + "?" + request.URL.RawQuery)
 }
 
 func TestRouter(t *testing.T) {
 	Describe(t, "router.Router", func() {
 		router := NewRouter()
+		router.LegacyQueryParams = true
 		router.Get("/a", dummyHandler)
 		router.Get("/:any", dummyHandler)
 		router.Get("/b", dummyHandler)
@@ -167,5 +174,222 @@ func TestRouter(t *testing.T) {
 				Expect(post(anyRouter, "/b").Code).To(Equal, 200)
 			})
 		})
+
+		Context("with Use middleware", func() {
+			mwRouter := NewRouter()
+			mwRouter.Use(headerMiddleware("X-Mw", "1"))
+			mwRouter.Get("/a", dummyHandler)
+			It("runs the middleware before the handler", func() {
+				response := get(mwRouter, "/a")
+				Expect(response.Code).To(Equal, 200)
+				Expect(response.Header().Get("X-Mw")).To(Equal, "1")
+			})
+		})
+
+		Context("with Group", func() {
+			groupRouter := NewRouter()
+			groupRouter.Get("/a", dummyHandler)
+			groupRouter.Group(func(group *Router) {
+				group.Use(headerMiddleware("X-Group", "1"))
+				group.Get("/b", dummyHandler)
+			})
+			It("scopes middleware to routes registered inside the group", func() {
+				Expect(get(groupRouter, "/a").Header().Get("X-Group")).To(Equal, "")
+				Expect(get(groupRouter, "/b").Header().Get("X-Group")).To(Equal, "1")
+			})
+		})
+
+		Context("with Route#With", func() {
+			withRouter := NewRouter()
+			withRouter.Get("/a", dummyHandler).With(headerMiddleware("X-With", "1"))
+			withRouter.Get("/b", dummyHandler)
+			It("scopes middleware to that single route", func() {
+				Expect(get(withRouter, "/a").Header().Get("X-With")).To(Equal, "1")
+				Expect(get(withRouter, "/b").Header().Get("X-With")).To(Equal, "")
+			})
+
+			var order []string
+			orderRouter := NewRouter()
+			orderRouter.Use(orderMiddleware(&order, "global"))
+			orderRouter.Get("/a", dummyHandler).With(orderMiddleware(&order, "with"))
+			It("runs Use middleware outside With middleware", func() {
+				get(orderRouter, "/a")
+				Expect(fmt.Sprint(order)).To(Equal, "[global with]")
+			})
+		})
+
+		Context("with Mount", func() {
+			apiRouter := NewRouter()
+			apiRouter.Get("/entries", dummyHandler)
+			mainRouter := NewRouter()
+			mainRouter.Get("/a", dummyHandler)
+			mainRouter.Mount("/api/v2", apiRouter)
+			It("delegates matching paths to the mounted subrouter", func() {
+				Expect(get(mainRouter, "/a").Code).To(Equal, 200)
+				Expect(get(mainRouter, "/api/v2/entries").Code).To(Equal, 200)
+				Expect(get(mainRouter, "/api/v2/missing").Code).To(Equal, 404)
+			})
+			It("strips the prefix before delegating", func() {
+				Expect(get(mainRouter, "/api/v2/entries").Body.String()).To(Equal, "/entries?")
+			})
+		})
+
+		Context("with PathPrefix", func() {
+			prefixMainRouter := NewRouter()
+			apiSubRouter := prefixMainRouter.PathPrefix("/api")
+			apiSubRouter.Host("api.example.com")
+			apiSubRouter.Get("/b", dummyHandler)
+			It("assembles a host-scoped subrouter under a path prefix", func() {
+				Expect(get(prefixMainRouter, "/api/b").Code).To(Equal, 404)
+				Expect(get(prefixMainRouter, "http://api.example.com/api/b").Code).To(Equal, 200)
+			})
+		})
+
+		Context("with HandleMethodNotAllowed", func() {
+			mnaRouter := NewRouter()
+			mnaRouter.HandleMethodNotAllowed = true
+			mnaRouter.Get("/a", dummyHandler)
+			It("responds 405 with an Allow header for a matched path and unmatched method", func() {
+				response := post(mnaRouter, "/a")
+				Expect(response.Code).To(Equal, 405)
+				Expect(response.Header().Get("Allow")).To(Equal, "GET")
+			})
+			It("still responds 404 for an entirely unmatched path", func() {
+				Expect(post(mnaRouter, "/missing").Code).To(Equal, 404)
+			})
+		})
+
+		Context("with AutoOptions", func() {
+			autoRouter := NewRouter()
+			autoRouter.AutoOptions = true
+			autoRouter.Get("/a", dummyHandler)
+			It("auto-serves HEAD from the GET route with an empty body", func() {
+				response := request(autoRouter, "HEAD", "/a")
+				Expect(response.Code).To(Equal, 200)
+				Expect(response.Body.String()).To(Equal, "")
+			})
+			It("auto-responds to OPTIONS with an Allow header", func() {
+				response := request(autoRouter, "OPTIONS", "/a")
+				Expect(response.Code).To(Equal, 200)
+				Expect(response.Header().Get("Allow")).To(Equal, "GET")
+			})
+		})
+
+		Context("with a constrained route", func() {
+			constrainedRouter := NewRouter()
+			constrainedRouter.LegacyQueryParams = true
+			constrainedRouter.Get("/users/new", dummyHandler)
+			constrainedRouter.Get("/users/{id:[0-9]+}", dummyHandler)
+			It("prefers the static route over the constrained placeholder", func() {
+				Expect(get(constrainedRouter, "/users/new").Body.String()).To(Equal, "/users/new?")
+			})
+			It("matches the placeholder when the constraint is satisfied", func() {
+				Expect(get(constrainedRouter, "/users/42").Body.String()).To(Equal, "/users/42?id=42")
+			})
+			It("does not match when the constraint is not satisfied", func() {
+				Expect(get(constrainedRouter, "/users/abc").Code).To(Equal, 404)
+			})
+		})
+
+		Context("with a wildcard route", func() {
+			wildcardRouter := NewRouter()
+			wildcardRouter.LegacyQueryParams = true
+			wildcardRouter.Get("/files/*path", dummyHandler)
+			It("captures the rest of the path", func() {
+				Expect(get(wildcardRouter, "/files/a/b/c.txt").Body.String()).To(Equal, "/files/a/b/c.txt?path=a%2Fb%2Fc.txt")
+			})
+		})
+
+		Context("with a named route", func() {
+			namedRouter := NewRouter()
+			namedRouter.Get("/entries/:id", dummyHandler).Name("entry")
+			It("reverses the pattern via Router#URL", func() {
+				url, err := namedRouter.URL("entry", "id", "42")
+				Expect(err).To(Equal, nil)
+				Expect(url.String()).To(Equal, "/entries/42")
+			})
+			It("returns an error for an unknown name", func() {
+				_, err := namedRouter.URL("nope")
+				Expect(err == nil).To(Equal, false)
+			})
+			It("reverses a constrained {name:regex} placeholder", func() {
+				namedRouter.Get("/users/{id:[0-9]+}", dummyHandler).Name("user")
+				url, err := namedRouter.URL("user", "id", "42")
+				Expect(err).To(Equal, nil)
+				Expect(url.String()).To(Equal, "/users/42")
+			})
+			It("reverses a *name wildcard placeholder", func() {
+				namedRouter.Get("/files/*path", dummyHandler).Name("file")
+				url, err := namedRouter.URL("file", "path", "a/b.txt")
+				Expect(err).To(Equal, nil)
+				Expect(url.String()).To(Equal, "/files/a%2Fb.txt")
+			})
+			It("path-escapes a value containing a space", func() {
+				namedRouter.Get("/greet/:name", dummyHandler).Name("greet")
+				url, err := namedRouter.URL("greet", "name", "john doe")
+				Expect(err).To(Equal, nil)
+				Expect(url.String()).To(Equal, "/greet/john%20doe")
+			})
+		})
+
+		Context("with path params", func() {
+			paramsRouter := NewRouter()
+			paramsRouter.Get("/entries/:id", func(writer http.ResponseWriter, request *http.Request) {
+				fmt.Fprintf(writer, Param(request, "id"))
+			})
+			It("exposes path params via Param without touching RawQuery", func() {
+				response := get(paramsRouter, "/entries/42?foo=bar")
+				Expect(response.Body.String()).To(Equal, "42")
+			})
+			It("leaves RawQuery untouched by default", func() {
+				var rawQuery string
+				rawQueryRouter := NewRouter()
+				rawQueryRouter.Get("/entries/:id", func(writer http.ResponseWriter, request *http.Request) {
+					rawQuery = request.URL.RawQuery
+				})
+				get(rawQueryRouter, "/entries/42?foo=bar")
+				Expect(rawQuery).To(Equal, "foo=bar")
+			})
+			It("returns nil from Params when the route captured none", func() {
+				plainRouter := NewRouter()
+				var params map[string]string
+				plainRouter.Get("/a", func(writer http.ResponseWriter, request *http.Request) {
+					params = Params(request)
+				})
+				get(plainRouter, "/a")
+				Expect(len(params)).To(Equal, 0)
+			})
+		})
+
+		Context("with MiddlewareOnNotFound", func() {
+			notFoundMwRouter := NewRouter()
+			notFoundMwRouter.MiddlewareOnNotFound = true
+			notFoundMwRouter.Use(headerMiddleware("X-Mw", "1"))
+			It("runs middleware even for the 404 response", func() {
+				Expect(get(notFoundMwRouter, "/missing").Header().Get("X-Mw")).To(Equal, "1")
+			})
+		})
 	})
 }
+
+// Utility middleware that sets a response header, used to assert that a
+// middleware ran.
+func headerMiddleware(key, value string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.Header().Set(key, value)
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
+
+// Utility middleware that appends name to order before calling next, used
+// to assert the relative nesting order of several middlewares.
+func orderMiddleware(order *[]string, name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			*order = append(*order, name)
+			next.ServeHTTP(writer, request)
+		})
+	}
+}